@@ -0,0 +1,359 @@
+// Copyright 2012 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trie
+
+// A cursorFrame is one node on the explicit DFS stack a Cursor keeps
+// instead of recursing: node is the Trie node itself, entryLen is the
+// length buf had on entering node (restored when the frame is popped),
+// keyLen is the length of the key ending at node's own value (entryLen
+// plus len(node.suffix)), childIdx is the index, within node's sparse
+// list or dense slab, of the last child descended into (-1 before the
+// first), and emittedValue tracks whether node's own value has already
+// been returned by Next.
+type cursorFrame struct {
+	node         *Trie
+	entryLen     int
+	keyLen       int
+	childIdx     int
+	emittedValue bool
+}
+
+// A Cursor walks the key, value pairs of a Trie in sorted order,
+// pausing between calls to Next or Prev. It holds an explicit stack of
+// frames rather than recursing, so it is O(depth) in memory and can be
+// held across goroutines that don't mutate the Trie.
+type Cursor struct {
+	t       *Trie
+	buf     []byte
+	stack   []cursorFrame
+	key     string
+	haveKey bool
+}
+
+// Cursor returns a new Cursor positioned before the first key of t.
+func (t *Trie) Cursor() *Cursor {
+	c := &Cursor{t: t}
+	c.start()
+	return c
+}
+
+func (c *Cursor) start() {
+	c.buf = c.buf[:0]
+	c.stack = c.stack[:0]
+	c.key = ""
+	c.haveKey = false
+	c.pushAll(c.t)
+}
+
+// pushAll pushes a frame for t positioned to emit everything under it,
+// assuming buf already holds the bytes leading up to (but not
+// including) t's suffix.
+func (c *Cursor) pushAll(t *Trie) {
+	entryLen := len(c.buf)
+	c.buf = append(c.buf, t.suffix...)
+	c.stack = append(c.stack, cursorFrame{node: t, entryLen: entryLen, keyLen: len(c.buf), childIdx: -1})
+}
+
+// Seek repositions the cursor so that the next call to Next returns
+// the least key, value pair in the Trie with key >= the argument, if
+// any, and a call to Prev returns the greatest one with key < the
+// argument.
+func (c *Cursor) Seek(key string) {
+	c.buf = c.buf[:0]
+	c.stack = c.stack[:0]
+	c.key = key
+	c.haveKey = true
+	c.seek(c.t, key)
+}
+
+// seek appends to the stack and buf a path positioning the cursor so
+// Next returns the least key, value under t (reached by the bytes
+// already in buf) that is >= ks. It reports whether such a key exists.
+func (c *Cursor) seek(t *Trie, ks string) bool {
+	entryLen := len(c.buf)
+	c.buf = append(c.buf, t.suffix...)
+	keyLen := len(c.buf)
+
+	s := commonPrefix(t.suffix, ks)
+
+	if s < len(t.suffix) {
+		if s == len(ks) || t.suffix[s] > ks[s] {
+			// t.suffix (hence everything under t) is already >= ks.
+			c.stack = append(c.stack, cursorFrame{node: t, entryLen: entryLen, keyLen: keyLen, childIdx: -1})
+			return true
+		}
+		c.buf = c.buf[:entryLen]
+		return false
+	}
+
+	if s == len(ks) {
+		// ks is consumed exactly at t: include t's own value, then its children.
+		c.stack = append(c.stack, cursorFrame{node: t, entryLen: entryLen, keyLen: keyLen, childIdx: -1})
+		return true
+	}
+
+	// s == len(t.suffix) < len(ks): t's own value (a strict prefix of ks) is too
+	// small; descend into the child for ks[s], or else the next greater one.
+	cb := ks[s]
+	idx, b, child, exact := t.firstChildGE(cb)
+	if child == nil {
+		c.buf = c.buf[:entryLen]
+		return false
+	}
+
+	if exact {
+		c.stack = append(c.stack, cursorFrame{node: t, entryLen: entryLen, keyLen: keyLen, childIdx: idx, emittedValue: true})
+		c.buf = append(c.buf[:keyLen], b)
+		if c.seek(child, ks[s+1:]) {
+			return true
+		}
+		// the exact child had nothing >= the rest of ks; fall through to the
+		// next sibling, whose first byte already exceeds cb.
+		c.stack = c.stack[:len(c.stack)-1]
+		c.buf = c.buf[:keyLen]
+
+		idx, b, child = t.nthChildAfter(idx)
+		if child == nil {
+			c.buf = c.buf[:entryLen]
+			return false
+		}
+	}
+
+	// child's first byte is strictly greater than cb, so everything under it
+	// already qualifies.
+	c.stack = append(c.stack, cursorFrame{node: t, entryLen: entryLen, keyLen: keyLen, childIdx: idx, emittedValue: true})
+	c.buf = append(c.buf[:keyLen], b)
+	c.pushAll(child)
+	return true
+}
+
+// Next returns the least key, value pair greater than the last one
+// returned by Next or Prev (right after Seek, the least one >= the
+// Seek argument; for a fresh cursor, the least key overall), or zero
+// values and false once the Trie is exhausted.
+// It runs in amortized O(1) off the explicit stack.
+func (c *Cursor) Next() (string, interface{}, bool) {
+	for len(c.stack) > 0 {
+		top := &c.stack[len(c.stack)-1]
+
+		if !top.emittedValue {
+			top.emittedValue = true
+			if top.node.value != nil {
+				k := string(c.buf[:top.keyLen])
+				c.key, c.haveKey = k, true
+				return k, top.node.value, true
+			}
+		}
+
+		nidx, b, child := top.node.nthChildAfter(top.childIdx)
+		if child == nil {
+			c.buf = c.buf[:top.entryLen]
+			c.stack = c.stack[:len(c.stack)-1]
+			continue
+		}
+		top.childIdx = nidx
+
+		c.buf = append(c.buf[:top.keyLen], b)
+		c.pushAll(child)
+	}
+	return "", nil, false
+}
+
+// Prev returns the greatest key, value pair less than the last one
+// returned by Next or Prev, or than the argument of a later Seek (or
+// the greatest key overall, for a fresh cursor), or zero values and
+// false if none exists. Unlike Next
+// it is not amortized O(1): each call searches down from the root,
+// O(depth).
+func (c *Cursor) Prev() (string, interface{}, bool) {
+	var k string
+	var v interface{}
+	var ok bool
+	if c.haveKey {
+		k, v, ok = predecessor(c.t, "", c.key)
+	} else {
+		k, v, ok = maxUnder(c.t, "")
+	}
+	if !ok {
+		return "", nil, false
+	}
+
+	c.Seek(k)
+	c.Next() // consume k itself, so a following Next resumes right after it
+	c.key, c.haveKey = k, true
+	return k, v, true
+}
+
+// firstChildGE returns the child reached by the smallest byte >= cb,
+// if any, along with its position (for nthChildAfter) and whether it
+// is an exact match for cb.
+func (t *Trie) firstChildGE(cb byte) (idx int, b byte, child *Trie, exact bool) {
+	if t.sparse != nil {
+		for i, e := range t.sparse {
+			if e.c >= cb {
+				return i, e.c, e.child, e.c == cb
+			}
+		}
+		return -1, 0, nil, false
+	}
+	for i := 0; i < len(t.children); i++ {
+		bc := t.base + byte(i)
+		if bc < cb {
+			continue
+		}
+		if !t.children[i].empty() {
+			return i, bc, &t.children[i], bc == cb
+		}
+	}
+	return -1, 0, nil, false
+}
+
+// nthChildAfter returns the child immediately after idx (as returned
+// by firstChildGE or a previous nthChildAfter), in sorted order, or
+// nil if there is none.
+func (t *Trie) nthChildAfter(idx int) (nidx int, b byte, child *Trie) {
+	if t.sparse != nil {
+		if idx+1 < len(t.sparse) {
+			e := t.sparse[idx+1]
+			return idx + 1, e.c, e.child
+		}
+		return idx, 0, nil
+	}
+	for i := idx + 1; i < len(t.children); i++ {
+		if !t.children[i].empty() {
+			return i, t.base + byte(i), &t.children[i]
+		}
+	}
+	return idx, 0, nil
+}
+
+// lastChildLT returns the child reached by the greatest byte < cb, if any.
+func (t *Trie) lastChildLT(cb byte) (b byte, child *Trie, ok bool) {
+	if t.sparse != nil {
+		for i := len(t.sparse) - 1; i >= 0; i-- {
+			if t.sparse[i].c < cb {
+				return t.sparse[i].c, t.sparse[i].child, true
+			}
+		}
+		return 0, nil, false
+	}
+	for i := len(t.children) - 1; i >= 0; i-- {
+		bc := t.base + byte(i)
+		if bc >= cb {
+			continue
+		}
+		if !t.children[i].empty() {
+			return bc, &t.children[i], true
+		}
+	}
+	return 0, nil, false
+}
+
+// predecessor returns the greatest key, value pair under t (reached by
+// the bytes already in acc) that is strictly less than bound (the
+// remaining, acc-relative portion of the original bound), or false if
+// none exists.
+func predecessor(t *Trie, acc, bound string) (string, interface{}, bool) {
+	s := commonPrefix(t.suffix, bound)
+
+	if s < len(t.suffix) {
+		if s < len(bound) && t.suffix[s] < bound[s] {
+			// t.suffix (hence everything under t) is already < bound.
+			return maxUnder(t, acc)
+		}
+		return "", nil, false
+	}
+
+	full := acc + t.suffix
+
+	if s == len(bound) {
+		// t's own key equals bound exactly; its children are all > bound.
+		return "", nil, false
+	}
+
+	cb := bound[s]
+
+	if child := t.child(cb); child != nil {
+		if k, v, ok := predecessor(child, full+string([]byte{cb}), bound[s+1:]); ok {
+			return k, v, true
+		}
+	}
+
+	if b, child, ok := t.lastChildLT(cb); ok {
+		return maxUnder(child, full+string([]byte{b}))
+	}
+
+	if t.value != nil {
+		return full, t.value, true
+	}
+
+	return "", nil, false
+}
+
+// maxUnder returns the greatest key, value pair under t, reached by
+// the bytes already in acc.
+func maxUnder(t *Trie, acc string) (string, interface{}, bool) {
+	full := acc + t.suffix
+
+	if t.sparse != nil {
+		if n := len(t.sparse); n > 0 {
+			e := t.sparse[n-1]
+			return maxUnder(e.child, full+string([]byte{e.c}))
+		}
+	} else {
+		for i := len(t.children) - 1; i >= 0; i-- {
+			if !t.children[i].empty() {
+				return maxUnder(&t.children[i], full+string([]byte{t.base + byte(i)}))
+			}
+		}
+	}
+
+	if t.value != nil {
+		return full, t.value, true
+	}
+	return "", nil, false
+}
+
+// NextKey returns the least key, value pair in t with key strictly
+// greater than the argument, or zero values and false if none exists.
+func (t *Trie) NextKey(key string) (string, interface{}, bool) {
+	c := t.Cursor()
+	c.Seek(key)
+	k, v, ok := c.Next()
+	if ok && k == key {
+		k, v, ok = c.Next()
+	}
+	if !ok {
+		return "", nil, false
+	}
+	return k, v, true
+}
+
+// RangeFunc applies f to every key, value pair in t with lo <= key <=
+// hi, in sorted order, without materializing the matches into a
+// slice. It stops as soon as f returns false.
+func (t *Trie) RangeFunc(lo, hi string, f func(string, interface{}) bool) {
+	c := t.Cursor()
+	c.Seek(lo)
+	for {
+		k, v, ok := c.Next()
+		if !ok || k > hi {
+			return
+		}
+		if !f(k, v) {
+			return
+		}
+	}
+}
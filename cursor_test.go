@@ -0,0 +1,277 @@
+// Copyright 2012 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trie
+
+import (
+	"sort"
+	"testing"
+)
+
+func mkSortedTC() (Trie, []string) {
+	tr, m := mktc()
+	var keys []string
+	for s := range m {
+		keys = append(keys, s)
+	}
+	sort.Strings(keys)
+	return tr, keys
+}
+
+func TestCursorNextAll(t *testing.T) {
+	tr, keys := mkSortedTC()
+
+	c := tr.Cursor()
+	var got []string
+	for {
+		k, v, ok := c.Next()
+		if !ok {
+			break
+		}
+		if v.(string) != k {
+			t.Errorf("Next() value = %v, want %v", v, k)
+		}
+		got = append(got, k)
+	}
+
+	if len(got) != len(keys) {
+		t.Fatalf("got %d keys, want %d", len(got), len(keys))
+	}
+	for i := range keys {
+		if got[i] != keys[i] {
+			t.Errorf("key %d = %q, want %q", i, got[i], keys[i])
+		}
+	}
+}
+
+func TestCursorSeek(t *testing.T) {
+	tr, keys := mkSortedTC()
+
+	for _, tc := range []struct{ seek string }{
+		{""},
+		{"a"},
+		{"abro"},
+		{"abrogable"},
+		{"abrogableX"},
+		{"zzzz"},
+	} {
+		c := tr.Cursor()
+		c.Seek(tc.seek)
+		var got []string
+		for {
+			k, v, ok := c.Next()
+			if !ok {
+				break
+			}
+			if v.(string) != k {
+				t.Errorf("Seek(%q) Next() value = %v, want %v", tc.seek, v, k)
+			}
+			got = append(got, k)
+		}
+
+		var want []string
+		for _, k := range keys {
+			if k >= tc.seek {
+				want = append(want, k)
+			}
+		}
+
+		if len(got) != len(want) {
+			t.Fatalf("Seek(%q): got %v, want %v", tc.seek, got, want)
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("Seek(%q): key %d = %q, want %q", tc.seek, i, got[i], want[i])
+			}
+		}
+	}
+}
+
+func TestCursorPrev(t *testing.T) {
+	tr, keys := mkSortedTC()
+
+	c := tr.Cursor()
+	var got []string
+	for {
+		k, _, ok := c.Prev()
+		if !ok {
+			break
+		}
+		got = append(got, k)
+	}
+
+	if len(got) != len(keys) {
+		t.Fatalf("got %d keys, want %d", len(got), len(keys))
+	}
+	for i := range keys {
+		// got is in descending order
+		if got[i] != keys[len(keys)-1-i] {
+			t.Errorf("key %d = %q, want %q", i, got[i], keys[len(keys)-1-i])
+		}
+	}
+}
+
+func TestCursorNextThenPrev(t *testing.T) {
+	tr, keys := mkSortedTC()
+	if len(keys) < 3 {
+		t.Fatal("need at least 3 keys")
+	}
+
+	c := tr.Cursor()
+	k1, _, _ := c.Next()
+	k2, _, _ := c.Next()
+	if k1 != keys[0] || k2 != keys[1] {
+		t.Fatalf("Next, Next = %q, %q, want %q, %q", k1, k2, keys[0], keys[1])
+	}
+
+	back, _, ok := c.Prev()
+	if !ok || back != k1 {
+		t.Errorf("Prev() after two Nexts = %q, %v, want %q, true", back, ok, k1)
+	}
+
+	fwd, _, ok := c.Next()
+	if !ok || fwd != k2 {
+		t.Errorf("Next() after Prev() = %q, %v, want %q, true", fwd, ok, k2)
+	}
+}
+
+func TestNextKey(t *testing.T) {
+	var tr Trie
+	tr.Put("a", 1)
+	tr.Put("c", 3)
+	tr.Put("e", 5)
+
+	for _, tc := range []struct {
+		key  string
+		want string
+		ok   bool
+	}{
+		{"", "a", true},
+		{"a", "c", true},
+		{"b", "c", true},
+		{"c", "e", true},
+		{"e", "", false},
+		{"z", "", false},
+	} {
+		k, _, ok := tr.NextKey(tc.key)
+		if ok != tc.ok || k != tc.want {
+			t.Errorf("NextKey(%q) = %q, %v, want %q, %v", tc.key, k, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestRangeFunc(t *testing.T) {
+	tr, keys := mkSortedTC()
+
+	var lo, hi string
+	if len(keys) > 4 {
+		lo, hi = keys[1], keys[len(keys)-2]
+	}
+
+	var got []string
+	tr.RangeFunc(lo, hi, func(k string, v interface{}) bool {
+		got = append(got, k)
+		return true
+	})
+
+	var want []string
+	for _, k := range keys {
+		if k >= lo && k <= hi {
+			want = append(want, k)
+		}
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("RangeFunc(%q, %q) = %v, want %v", lo, hi, got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("RangeFunc(%q, %q): key %d = %q, want %q", lo, hi, i, got[i], want[i])
+		}
+	}
+}
+
+// forces a dense-mode node (more than sparseMax children) through
+// Cursor, exercising the t.children/base branches of firstChildGE,
+// nthChildAfter, lastChildLT and maxUnder.
+func TestCursorWideFanout(t *testing.T) {
+	var tr Trie
+	const n = 64
+	var keys []string
+	for i := 0; i < n; i++ {
+		k := string([]byte{byte(i)}) + "tail"
+		tr.Put(k, i)
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	c := tr.Cursor()
+	var got []string
+	for {
+		k, v, ok := c.Next()
+		if !ok {
+			break
+		}
+		if v.(int) != int(k[0]) {
+			t.Errorf("Next() value = %v, want %d", v, int(k[0]))
+		}
+		got = append(got, k)
+	}
+	if len(got) != len(keys) {
+		t.Fatalf("got %d keys, want %d", len(got), len(keys))
+	}
+	for i := range keys {
+		if got[i] != keys[i] {
+			t.Errorf("key %d = %q, want %q", i, got[i], keys[i])
+		}
+	}
+
+	c = tr.Cursor()
+	var back []string
+	for {
+		k, _, ok := c.Prev()
+		if !ok {
+			break
+		}
+		back = append(back, k)
+	}
+	if len(back) != len(keys) {
+		t.Fatalf("got %d keys, want %d", len(back), len(keys))
+	}
+	for i := range keys {
+		if back[i] != keys[len(keys)-1-i] {
+			t.Errorf("key %d = %q, want %q", i, back[i], keys[len(keys)-1-i])
+		}
+	}
+
+	mid := string([]byte{byte(n / 2)}) + "tail"
+	c = tr.Cursor()
+	c.Seek(mid)
+	if k, _, ok := c.Next(); !ok || k != mid {
+		t.Errorf("Seek(%q) then Next() = %q, %v, want %q, true", mid, k, ok, mid)
+	}
+}
+
+func TestRangeFuncStopsEarly(t *testing.T) {
+	tr, _ := mkSortedTC()
+
+	n := 0
+	tr.RangeFunc("", "zzzzzz", func(k string, v interface{}) bool {
+		n++
+		return false
+	})
+	if n != 1 {
+		t.Errorf("expected exactly one callback, got %d", n)
+	}
+}
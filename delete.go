@@ -0,0 +1,158 @@
+// Copyright 2012 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trie
+
+// Len returns the number of keys with a non-nil value in the trie. It
+// is maintained incrementally by Put and Delete, so it is O(1).
+func (t *Trie) Len() int { return t.count }
+
+// Delete removes key from the trie, returning its previous value (or
+// nil) and whether it was present. A node left with neither a value
+// nor any children is detached from its parent, and a node left with
+// no value and exactly one remaining child is merged back together
+// with that child, undoing the split Put performs when it branches.
+func (t *Trie) Delete(key string) (interface{}, bool) {
+	v, found, _ := t.del(key)
+	if found {
+		t.count--
+	}
+	return v, found
+}
+
+// del is the recursive implementation of Delete. removeSelf reports
+// whether t itself is now empty and should be detached from its
+// parent's child storage.
+func (t *Trie) del(key string) (value interface{}, found bool, removeSelf bool) {
+	s := commonPrefix(t.suffix, key)
+
+	if s < len(t.suffix) {
+		return nil, false, false
+	}
+
+	if s == len(key) {
+		if t.value == nil {
+			return nil, false, false
+		}
+		value = t.value
+		t.value = nil
+		t.collapse()
+		return value, true, t.empty()
+	}
+
+	c := t.child(key[s])
+	if c == nil {
+		return nil, false, false
+	}
+
+	var childGone bool
+	value, found, childGone = c.del(key[s+1:])
+	if !found {
+		return value, false, false
+	}
+
+	if childGone {
+		t.removeChild(key[s])
+	}
+	t.collapse()
+
+	return value, true, t.empty()
+}
+
+// removeChild detaches the (now empty) child reached by byte c,
+// shrinking or demoting the remaining storage as needed.
+func (t *Trie) removeChild(c byte) {
+	if t.sparse != nil {
+		for i, e := range t.sparse {
+			if e.c == c {
+				t.sparse = append(t.sparse[:i], t.sparse[i+1:]...)
+				if len(t.sparse) == 0 {
+					t.sparse = nil
+				}
+				return
+			}
+		}
+		return
+	}
+
+	t.children[c-t.base] = Trie{}
+	t.shrinkDense()
+}
+
+// shrinkDense re-fits t's dense child slab, which must be in dense
+// mode, to its remaining contents: dropped entirely if empty, demoted
+// to sparse if sparseMax or fewer children remain, or re-sized to the
+// smallest power-of-two range that still covers them.
+func (t *Trie) shrinkDense() {
+	lo, hi, count := byte(0), byte(0), 0
+	for i := range t.children {
+		if t.children[i].empty() {
+			continue
+		}
+		b := t.base + byte(i)
+		if count == 0 {
+			lo = b
+		}
+		hi = b
+		count++
+	}
+
+	if count == 0 {
+		t.children = nil
+		t.base = 0
+		return
+	}
+
+	if count <= sparseMax {
+		sparse := make([]childEntry, 0, count)
+		for i := range t.children {
+			if !t.children[i].empty() {
+				ch := t.children[i]
+				sparse = append(sparse, childEntry{c: t.base + byte(i), child: &ch})
+			}
+		}
+		t.sparse = sparse
+		t.children = nil
+		t.base = 0
+		return
+	}
+
+	newbase, newlen := nextPow2Range(lo, 1, hi)
+	if newbase == t.base && newlen == len(t.children) {
+		return
+	}
+	newch := make([]Trie, newlen)
+	for i := range t.children {
+		if !t.children[i].empty() {
+			newch[t.base+byte(i)-newbase] = t.children[i]
+		}
+	}
+	t.children = newch
+	t.base = newbase
+}
+
+// collapse merges t's single remaining child back into t when t itself
+// holds no value: the inverse of the split Put performs to create a
+// branch.
+func (t *Trie) collapse() {
+	if t.value != nil || t.sparse == nil || len(t.sparse) != 1 {
+		return
+	}
+	e := t.sparse[0]
+	t.suffix = t.suffix + string([]byte{e.c}) + e.child.suffix
+	t.value = e.child.value
+	t.sparse = e.child.sparse
+	t.children = e.child.children
+	t.base = e.child.base
+}
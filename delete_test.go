@@ -0,0 +1,193 @@
+// Copyright 2012 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trie
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestLen(t *testing.T) {
+	var tr Trie
+	if tr.Len() != 0 {
+		t.Fatalf("Len() of empty trie = %d, want 0", tr.Len())
+	}
+
+	for i, s := range tcAA {
+		tr.Put(s, s)
+		if got, want := tr.Len(), i+1; got != want {
+			t.Errorf("after Put(%q): Len() = %d, want %d", s, got, want)
+		}
+	}
+
+	// replacing an existing key doesn't change Len
+	tr.Put(tcAA[0], "replaced")
+	if got, want := tr.Len(), len(tcAA); got != want {
+		t.Errorf("after replacing an existing key: Len() = %d, want %d", got, want)
+	}
+
+	// Put(key, nil) on a fresh key doesn't increase Len
+	tr.Put("brand-new-but-nil", nil)
+	if got, want := tr.Len(), len(tcAA); got != want {
+		t.Errorf("after Put(_, nil) of a new key: Len() = %d, want %d", got, want)
+	}
+}
+
+func TestDeleteBasic(t *testing.T) {
+	var tr Trie
+	for _, s := range tcAA {
+		tr.Put(s, s)
+	}
+
+	n := len(tcAA)
+	for _, s := range tcAA {
+		v, ok := tr.Delete(s)
+		if !ok || v.(string) != s {
+			t.Fatalf("Delete(%q) = %v, %v, want %q, true", s, v, ok, s)
+		}
+		n--
+		if got := tr.Len(); got != n {
+			t.Errorf("after Delete(%q): Len() = %d, want %d", s, got, n)
+		}
+		if tr.Get(s) != nil {
+			t.Errorf("Get(%q) after Delete = %v, want nil", s, tr.Get(s))
+		}
+	}
+
+	if tr.Len() != 0 {
+		t.Errorf("Len() after deleting everything = %d, want 0", tr.Len())
+	}
+	if !tr.empty() {
+		t.Error("trie should be fully collapsed back to empty after deleting every key")
+	}
+}
+
+func TestDeleteMissing(t *testing.T) {
+	var tr Trie
+	tr.Put("abc", 1)
+
+	if v, ok := tr.Delete("xyz"); ok || v != nil {
+		t.Errorf("Delete(%q) = %v, %v, want nil, false", "xyz", v, ok)
+	}
+	if v, ok := tr.Delete("ab"); ok || v != nil {
+		t.Errorf("Delete(%q) = %v, %v, want nil, false", "ab", v, ok)
+	}
+	if v, ok := tr.Delete("abc"); !ok || v != 1 {
+		t.Errorf("Delete(%q) = %v, %v, want 1, true", "abc", v, ok)
+	}
+	if v, ok := tr.Delete("abc"); ok || v != nil {
+		t.Errorf("second Delete(%q) = %v, %v, want nil, false", "abc", v, ok)
+	}
+}
+
+func TestDeleteCollapses(t *testing.T) {
+	var tr Trie
+	tr.Put("abcdef", 1)
+	tr.Put("abcxyz", 2)
+
+	// deleting one of the two branches should merge the survivor back
+	// into the shared prefix node, not leave a dangling branch point.
+	if _, ok := tr.Delete("abcxyz"); !ok {
+		t.Fatal("Delete(abcxyz) failed")
+	}
+
+	if v := tr.Get("abcdef"); v != 1 {
+		t.Errorf("Get(abcdef) = %v, want 1", v)
+	}
+	if v := tr.Get("abcxyz"); v != nil {
+		t.Errorf("Get(abcxyz) after delete = %v, want nil", v)
+	}
+	if tr.suffix != "abcdef" || tr.sparse != nil || tr.children != nil {
+		t.Errorf("root after delete = suffix %q, %d sparse, %d dense children; want suffix %q and no children",
+			tr.suffix, len(tr.sparse), len(tr.children), "abcdef")
+	}
+
+	// ForEach should still see exactly the one remaining key, in order.
+	var got []string
+	tr.ForEach(func(k string, v interface{}) bool {
+		got = append(got, k)
+		return true
+	})
+	if len(got) != 1 || got[0] != "abcdef" {
+		t.Errorf("ForEach() = %v, want [abcdef]", got)
+	}
+}
+
+func TestDeleteManyRandomOrder(t *testing.T) {
+	const n = 2000
+	m := make(map[string]int, n)
+	for len(m) < n {
+		var b []byte
+		for l := rand.Intn(6) + 1; l > 0; l-- {
+			b = append(b, byte('a'+rand.Intn(26)))
+		}
+		m[string(b)] = len(m)
+	}
+
+	var tr Trie
+	keys := make([]string, 0, n)
+	for k, v := range m {
+		tr.Put(k, v)
+		keys = append(keys, k)
+	}
+
+	rand.Shuffle(len(keys), func(i, j int) { keys[i], keys[j] = keys[j], keys[i] })
+
+	remaining := n
+	for _, k := range keys {
+		if v, ok := tr.Delete(k); !ok || v.(int) != m[k] {
+			t.Fatalf("Delete(%q) = %v, %v, want %d, true", k, v, ok, m[k])
+		}
+		remaining--
+		if got := tr.Len(); got != remaining {
+			t.Fatalf("after deleting %q: Len() = %d, want %d", k, got, remaining)
+		}
+	}
+
+	if !tr.empty() {
+		t.Error("trie should be empty after deleting every key")
+	}
+}
+
+// forces dense-mode nodes (more than sparseMax children) to exercise
+// shrinkDense's demotion and resize paths.
+func TestDeleteWideFanout(t *testing.T) {
+	var tr Trie
+	const n = 64
+	for i := 0; i < n; i++ {
+		tr.Put(string([]byte{byte(i)})+"tail", i)
+	}
+	if tr.Len() != n {
+		t.Fatalf("Len() = %d, want %d", tr.Len(), n)
+	}
+
+	// delete down to a handful of children, forcing the dense slab to
+	// shrink and eventually demote to sparse.
+	for i := 0; i < n-2; i++ {
+		k := string([]byte{byte(i)}) + "tail"
+		if _, ok := tr.Delete(k); !ok {
+			t.Fatalf("Delete(%q) failed", k)
+		}
+	}
+	if tr.Len() != 2 {
+		t.Fatalf("Len() = %d, want 2", tr.Len())
+	}
+	for i := n - 2; i < n; i++ {
+		k := string([]byte{byte(i)}) + "tail"
+		if v := tr.Get(k); v != i {
+			t.Errorf("Get(%q) = %v, want %d", k, v, i)
+		}
+	}
+}
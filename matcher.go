@@ -0,0 +1,194 @@
+// Copyright 2012 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trie
+
+import "io"
+
+// acState is one state of the compiled Aho-Corasick automaton. goTo is
+// a fully resolved transition function (every one of the 256 possible
+// input bytes maps to a state, falling back through failure links as
+// necessary), so matching is a single array lookup per input byte.
+type acState struct {
+	goTo   [256]int32
+	fail   int32
+	out    int32 // nearest proper failure-ancestor with outVal set, or -1
+	outVal *KV
+}
+
+// A Matcher is an automaton, compiled from a Trie, that reports every
+// occurrence of every key the Trie held at compile time during a
+// single left-to-right scan of an input.
+type Matcher struct {
+	states []acState
+}
+
+// CompileMatcher builds a Matcher from the keys and values currently
+// in t by adding Aho-Corasick failure and output links to (a shadow
+// copy of) t's trie. Since t is edge-compressed, each node's suffix is
+// expanded into a chain of single-byte states while building the
+// shadow automaton. Later Puts on t are not reflected in the returned
+// Matcher.
+func (t *Trie) CompileMatcher() *Matcher {
+	b := &acBuilder{}
+	b.addState()
+	b.build(t, 0, "")
+	b.link()
+	return &Matcher{states: b.states}
+}
+
+type acBuilder struct {
+	states []acState
+}
+
+func (b *acBuilder) addState() int32 {
+	var s acState
+	for c := range s.goTo {
+		s.goTo[c] = -1
+	}
+	s.out = -1
+	b.states = append(b.states, s)
+	return int32(len(b.states) - 1)
+}
+
+// build expands t (reached so far by the bytes in prefix) into the
+// shadow automaton rooted at cur, recursing into t's children.
+func (b *acBuilder) build(t *Trie, cur int32, prefix string) {
+	full := prefix + t.suffix
+	for i := 0; i < len(t.suffix); i++ {
+		next := b.addState()
+		b.states[cur].goTo[t.suffix[i]] = next
+		cur = next
+	}
+
+	if t.value != nil {
+		kv := KV{K: full, V: t.value}
+		b.states[cur].outVal = &kv
+	}
+
+	descend := func(c byte, child *Trie) {
+		next := b.addState()
+		b.states[cur].goTo[c] = next
+		b.build(child, next, full+string([]byte{c}))
+	}
+
+	if t.sparse != nil {
+		for _, e := range t.sparse {
+			descend(e.c, e.child)
+		}
+	} else {
+		c := t.base
+		for i := range t.children {
+			if !t.children[i].empty() {
+				descend(c, &t.children[i])
+			}
+			c++
+		}
+	}
+}
+
+// link runs the classic Aho-Corasick BFS over the shadow automaton,
+// turning each state's goTo from "real trie edges only" into a fully
+// resolved transition function, and filling in fail and out links.
+func (b *acBuilder) link() {
+	root := &b.states[0]
+	var queue []int32
+	for c := range root.goTo {
+		if root.goTo[c] == -1 {
+			root.goTo[c] = 0
+			continue
+		}
+		v := root.goTo[c]
+		b.states[v].fail = 0
+		if root.outVal != nil {
+			b.states[v].out = 0
+		}
+		queue = append(queue, v)
+	}
+
+	for len(queue) > 0 {
+		u := queue[0]
+		queue = queue[1:]
+		us := &b.states[u]
+		for c := range us.goTo {
+			v := us.goTo[c]
+			if v == -1 {
+				us.goTo[c] = b.states[us.fail].goTo[c]
+				continue
+			}
+			fv := b.states[us.fail].goTo[c]
+			b.states[v].fail = fv
+			if b.states[fv].outVal != nil {
+				b.states[v].out = fv
+			} else {
+				b.states[v].out = b.states[fv].out
+			}
+			queue = append(queue, v)
+		}
+	}
+}
+
+// report invokes f for the match ending at state, if any, followed by
+// every match reachable through its output link, in order from longest
+// to shortest. It returns false as soon as f does, to stop the scan.
+func (m *Matcher) report(state int32, end int64, f func(end int64, kv KV) bool) bool {
+	s := &m.states[state]
+	if s.outVal != nil && !f(end, *s.outVal) {
+		return false
+	}
+	for o := s.out; o != -1; o = m.states[o].out {
+		if !f(end, *m.states[o].outVal) {
+			return false
+		}
+	}
+	return true
+}
+
+// Match scans r and calls f with the end offset and KV of every
+// occurrence of every key the Matcher was compiled with. f may be
+// called more than once at the same offset, for nested or overlapping
+// matches ending there, longest first. Match stops and returns nil as
+// soon as f returns false.
+func (m *Matcher) Match(r io.Reader, f func(end int64, kv KV) bool) error {
+	var buf [4096]byte
+	var cur int32
+	var pos int64
+	for {
+		n, err := r.Read(buf[:])
+		for i := 0; i < n; i++ {
+			cur = m.states[cur].goTo[buf[i]]
+			pos++
+			if !m.report(cur, pos, f) {
+				return nil
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// MatchBytes is Match specialized for an in-memory input.
+func (m *Matcher) MatchBytes(b []byte, f func(end int64, kv KV) bool) {
+	var cur int32
+	for i, c := range b {
+		cur = m.states[cur].goTo[c]
+		if !m.report(cur, int64(i+1), f) {
+			return
+		}
+	}
+}
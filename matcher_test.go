@@ -0,0 +1,120 @@
+// Copyright 2012 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trie
+
+import (
+	"strings"
+	"testing"
+)
+
+type matchHit struct {
+	end int64
+	k   string
+}
+
+func collectMatches(tr *Trie, s string) []matchHit {
+	var hits []matchHit
+	tr.CompileMatcher().MatchBytes([]byte(s), func(end int64, kv KV) bool {
+		hits = append(hits, matchHit{end, kv.K})
+		return true
+	})
+	return hits
+}
+
+func TestMatcher(t *testing.T) {
+	var tr Trie
+	for _, s := range []string{"he", "she", "his", "hers"} {
+		tr.Put(s, s)
+	}
+
+	// classic Aho-Corasick textbook example
+	got := collectMatches(&tr, "ushers")
+	want := []matchHit{
+		{4, "she"},
+		{4, "he"},
+		{6, "hers"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("collectMatches() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("match %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestMatcherStopsEarly(t *testing.T) {
+	var tr Trie
+	for _, s := range []string{"a", "b", "c"} {
+		tr.Put(s, s)
+	}
+	n := 0
+	tr.CompileMatcher().MatchBytes([]byte("abc"), func(end int64, kv KV) bool {
+		n++
+		return false
+	})
+	if n != 1 {
+		t.Errorf("expected exactly one callback before stopping, got %d", n)
+	}
+}
+
+func TestMatcherReader(t *testing.T) {
+	var tr Trie
+	tr.Put("needle", "found")
+
+	var hits []matchHit
+	err := tr.CompileMatcher().Match(strings.NewReader("a haystack with a needle in it"), func(end int64, kv KV) bool {
+		hits = append(hits, matchHit{end, kv.K})
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hits) != 1 || hits[0].k != "needle" {
+		t.Errorf("Match() = %+v, want one hit for %q", hits, "needle")
+	}
+}
+
+func TestMatcherNoMatches(t *testing.T) {
+	var tr Trie
+	tr.Put("xyz", 1)
+	if got := collectMatches(&tr, "abcdef"); got != nil {
+		t.Errorf("collectMatches() = %+v, want none", got)
+	}
+}
+
+// forces a dense-mode node (more than sparseMax children) through
+// CompileMatcher, exercising build()'s t.children/base branch.
+func TestMatcherWideFanout(t *testing.T) {
+	var tr Trie
+	const n = 64
+	for i := 0; i < n; i++ {
+		tr.Put(string([]byte{byte(i)})+"tail", i)
+	}
+
+	m := tr.CompileMatcher()
+	for i := 0; i < n; i++ {
+		k := string([]byte{byte(i)}) + "tail"
+		var hits []matchHit
+		m.MatchBytes([]byte(k), func(end int64, kv KV) bool {
+			hits = append(hits, matchHit{end, kv.K})
+			return true
+		})
+		if len(hits) != 1 || hits[0].k != k {
+			t.Errorf("MatchBytes(%q) = %+v, want one hit for %q", k, hits, k)
+		}
+	}
+}
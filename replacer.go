@@ -0,0 +1,104 @@
+// Copyright 2012 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trie
+
+import (
+	"bytes"
+	"io"
+)
+
+// A Replacer replaces a list of strings with replacements, the way
+// strings.Replacer does, but matches against the old strings using a
+// Trie instead of a hand-rolled trie: at every position it takes the
+// longest of the old strings that matches there and emits the
+// corresponding replacement.
+type Replacer struct {
+	t Trie
+}
+
+// NewReplacer returns a new Replacer from a list of old, new string
+// pairs. Replacements are performed without overlapping matches,
+// preferring, at each position, the longest old string that matches
+// there; an old string of "" is independent of this and, if
+// registered, additionally inserts its replacement at every position
+// without consuming input, the same way strings.Replacer does.
+// NewReplacer panics if given an odd number of arguments.
+func NewReplacer(pairs ...string) *Replacer {
+	if len(pairs)%2 != 0 {
+		panic("trie.NewReplacer: odd argument count")
+	}
+	r := &Replacer{}
+	for i := 0; i < len(pairs); i += 2 {
+		r.t.Put(pairs[i], pairs[i+1])
+	}
+	return r
+}
+
+// Replace returns a copy of s with all replacements performed.
+func (r *Replacer) Replace(s string) string {
+	var buf bytes.Buffer
+	r.WriteString(&buf, s)
+	return buf.String()
+}
+
+// WriteString writes s to w with all replacements performed, and
+// returns the number of bytes written and any write error.
+func (r *Replacer) WriteString(w io.Writer, s string) (n int, err error) {
+	// emitEmpty writes the replacement for an old string of "", if
+	// registered, without consuming any input. It fires independently
+	// of whatever longer match is found at the same position, the same
+	// way strings.Replacer treats "" as a special case.
+	emitEmpty := func() error {
+		if val := r.t.Get(""); val != nil {
+			nn, err := io.WriteString(w, val.(string))
+			n += nn
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for i := 0; i < len(s); {
+		if err := emitEmpty(); err != nil {
+			return n, err
+		}
+
+		pfx, val := r.t.FindPfx(s[i:])
+		if val == nil || len(pfx) == 0 { // no match, or only the "" match
+			nn, err := io.WriteString(w, s[i:i+1])
+			n += nn
+			if err != nil {
+				return n, err
+			}
+			i++
+			continue
+		}
+
+		nn, err := io.WriteString(w, val.(string))
+		n += nn
+		if err != nil {
+			return n, err
+		}
+		i += len(pfx)
+	}
+
+	// "" also matches once more after the last byte.
+	if err := emitEmpty(); err != nil {
+		return n, err
+	}
+
+	return n, nil
+}
@@ -0,0 +1,84 @@
+// Copyright 2012 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trie
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestReplacer(t *testing.T) {
+	for _, tc := range []struct {
+		pairs []string
+		in    string
+		out   string
+	}{
+		{[]string{"<", "&lt;", ">", "&gt;"}, "<b>", "&lt;b&gt;"},
+		{[]string{"a", "1", "ab", "2"}, "ab", "2"}, // longest match wins
+		{[]string{"a", "X"}, "a", "X"},             // single byte old string
+		{[]string{"a", "X"}, "banana", "bXnXnX"},
+		{[]string{}, "unchanged", "unchanged"},
+		{[]string{"", "-"}, "abc", "-a-b-c-"},           // empty old string
+		{[]string{"", "-", "b", "B"}, "abc", "-a-B-c-"}, // "" applies at every position, independent of longer matches
+	} {
+		if got := NewReplacer(tc.pairs...).Replace(tc.in); got != tc.out {
+			t.Errorf("NewReplacer(%q).Replace(%q) = %q, want %q", tc.pairs, tc.in, got, tc.out)
+		}
+	}
+}
+
+func TestReplacerOddArgsPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("NewReplacer with an odd number of arguments should panic")
+		}
+	}()
+	NewReplacer("a")
+}
+
+func manyPairs(n int) []string {
+	pairs := make([]string, 0, 2*n)
+	for i := 0; i < n; i++ {
+		pairs = append(pairs, fmt.Sprintf("pattern%04d", i), fmt.Sprintf("<%d>", i))
+	}
+	return pairs
+}
+
+func benchInput(n int) string {
+	var b strings.Builder
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&b, "pattern%04d-", i%n)
+	}
+	return b.String()
+}
+
+func BenchmarkTrieReplacer500(b *testing.B) {
+	r := NewReplacer(manyPairs(500)...)
+	in := benchInput(500)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Replace(in)
+	}
+}
+
+func BenchmarkStringsReplacer500(b *testing.B) {
+	r := strings.NewReplacer(manyPairs(500)...)
+	in := benchInput(500)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Replace(in)
+	}
+}
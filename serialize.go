@@ -0,0 +1,338 @@
+// Copyright 2012 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trie
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"io"
+)
+
+// The on-disk format preserves edge compression: each node is
+//
+//	uvarint(len(suffix)) suffix-bytes
+//	flag-byte (1 if a value follows, else 0) [uvarint(len(value)) value-bytes]
+//	uvarint(childCount)
+//	childCount * (byte uvarint(len(subtree)) subtree)
+//
+// Only real children are written -- dense mode's power-of-two padding
+// never reaches the wire -- and every child is length-prefixed so a
+// reader can skip over (or, for Frozen, jump straight past) a subtree
+// without decoding it.
+
+func writeUvarint(buf *bytes.Buffer, v uint64) {
+	var tmp [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(tmp[:], v)
+	buf.Write(tmp[:n])
+}
+
+// sortedChildren returns t's real children as (byte, *Trie) pairs in
+// ascending order, regardless of whether t is in sparse or dense mode.
+func (t *Trie) sortedChildren() []childEntry {
+	if t.sparse != nil {
+		return t.sparse
+	}
+	var out []childEntry
+	for i := range t.children {
+		if !t.children[i].empty() {
+			out = append(out, childEntry{c: t.base + byte(i), child: &t.children[i]})
+		}
+	}
+	return out
+}
+
+func encodeNode(t *Trie, encodeValue func(interface{}) ([]byte, error)) ([]byte, error) {
+	var buf bytes.Buffer
+
+	writeUvarint(&buf, uint64(len(t.suffix)))
+	buf.WriteString(t.suffix)
+
+	if t.value != nil {
+		vb, err := encodeValue(t.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteByte(1)
+		writeUvarint(&buf, uint64(len(vb)))
+		buf.Write(vb)
+	} else {
+		buf.WriteByte(0)
+	}
+
+	children := t.sortedChildren()
+	writeUvarint(&buf, uint64(len(children)))
+	for _, e := range children {
+		sub, err := encodeNode(e.child, encodeValue)
+		if err != nil {
+			return nil, err
+		}
+		buf.WriteByte(e.c)
+		writeUvarint(&buf, uint64(len(sub)))
+		buf.Write(sub)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Encode writes t's structure to w, encoding each value with
+// encodeValue. Use WriteTo for the encoding/gob-based default.
+func (t *Trie) Encode(w io.Writer, encodeValue func(interface{}) ([]byte, error)) (int64, error) {
+	b, err := encodeNode(t, encodeValue)
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.Write(b)
+	return int64(n), err
+}
+
+// byteCursor reads the uvarint/length-prefixed primitives of the wire
+// format off of a []byte, tracking position without copying.
+type byteCursor struct {
+	b   []byte
+	pos int
+}
+
+var errCorrupt = errors.New("trie: corrupt or truncated data")
+
+func (c *byteCursor) uvarint() (uint64, error) {
+	v, n := binary.Uvarint(c.b[c.pos:])
+	if n <= 0 {
+		return 0, errCorrupt
+	}
+	c.pos += n
+	return v, nil
+}
+
+func (c *byteCursor) byte1() (byte, error) {
+	if c.pos >= len(c.b) {
+		return 0, errCorrupt
+	}
+	b := c.b[c.pos]
+	c.pos++
+	return b, nil
+}
+
+func (c *byteCursor) bytes(n int) ([]byte, error) {
+	if n < 0 || c.pos+n > len(c.b) {
+		return nil, errCorrupt
+	}
+	b := c.b[c.pos : c.pos+n]
+	c.pos += n
+	return b, nil
+}
+
+// decodeNode parses one node of the wire format from c, Put-ing every
+// value it finds into root under its reconstructed key. prefix is the
+// key bytes accumulated on the path down to this node.
+func decodeNode(root *Trie, c *byteCursor, prefix string, decodeValue func([]byte) (interface{}, error)) error {
+	sl, err := c.uvarint()
+	if err != nil {
+		return err
+	}
+	sfx, err := c.bytes(int(sl))
+	if err != nil {
+		return err
+	}
+	full := prefix + string(sfx)
+
+	flag, err := c.byte1()
+	if err != nil {
+		return err
+	}
+	if flag == 1 {
+		vl, err := c.uvarint()
+		if err != nil {
+			return err
+		}
+		vb, err := c.bytes(int(vl))
+		if err != nil {
+			return err
+		}
+		val, err := decodeValue(vb)
+		if err != nil {
+			return err
+		}
+		root.Put(full, val)
+	}
+
+	n, err := c.uvarint()
+	if err != nil {
+		return err
+	}
+	for i := uint64(0); i < n; i++ {
+		cb, err := c.byte1()
+		if err != nil {
+			return err
+		}
+		if _, err := c.uvarint(); err != nil { // subtree length, unused by this recursive decode
+			return err
+		}
+		if err := decodeNode(root, c, full+string([]byte{cb}), decodeValue); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Decode replaces t's contents with a trie read from r, previously
+// written by Encode, decoding each value with decodeValue. Use
+// ReadFrom for the encoding/gob-based default.
+func (t *Trie) Decode(r io.Reader, decodeValue func([]byte) (interface{}, error)) (int64, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return 0, err
+	}
+	*t = Trie{}
+	c := &byteCursor{b: data}
+	if err := decodeNode(t, c, "", decodeValue); err != nil {
+		return int64(c.pos), err
+	}
+	return int64(c.pos), nil
+}
+
+func gobEncodeValue(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func gobDecodeValue(b []byte) (interface{}, error) {
+	var v interface{}
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler, encoding values
+// with encoding/gob. Concrete value types must be registered with
+// gob.Register beforehand, as usual for gob and interface{}.
+func (t *Trie) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := t.Encode(&buf, gobEncodeValue); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler, the inverse
+// of MarshalBinary.
+func (t *Trie) UnmarshalBinary(data []byte) error {
+	_, err := t.Decode(bytes.NewReader(data), gobDecodeValue)
+	return err
+}
+
+// WriteTo implements io.WriterTo, encoding values with encoding/gob.
+func (t *Trie) WriteTo(w io.Writer) (int64, error) {
+	return t.Encode(w, gobEncodeValue)
+}
+
+// ReadFrom implements io.ReaderFrom, the inverse of WriteTo.
+func (t *Trie) ReadFrom(r io.Reader) (int64, error) {
+	return t.Decode(r, gobDecodeValue)
+}
+
+// A Frozen is a read-only view of the structural bytes Encode or
+// WriteTo produced, looked up directly over a []byte -- e.g. one
+// obtained from mmap -- without allocating a Trie node per key. It
+// trades the ability to modify the trie for zero-allocation lookups
+// over a large, static table.
+type Frozen struct {
+	data        []byte
+	decodeValue func([]byte) (interface{}, error)
+}
+
+// NewFrozen wraps data, the structural bytes written by Encode or
+// WriteTo, for lookup without reconstructing a Trie.
+func NewFrozen(data []byte, decodeValue func([]byte) (interface{}, error)) *Frozen {
+	return &Frozen{data: data, decodeValue: decodeValue}
+}
+
+// Get looks up key, decoding its value with the func given to
+// NewFrozen. It returns a nil value if key is not present.
+func (f *Frozen) Get(key string) (interface{}, error) {
+	return getFrozen(f.data, key, f.decodeValue)
+}
+
+func getFrozen(node []byte, key string, decodeValue func([]byte) (interface{}, error)) (interface{}, error) {
+	c := &byteCursor{b: node}
+
+	sl, err := c.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	sfx, err := c.bytes(int(sl))
+	if err != nil {
+		return nil, err
+	}
+
+	s := commonPrefix(string(sfx), key)
+	if s < len(sfx) {
+		return nil, nil
+	}
+
+	flag, err := c.byte1()
+	if err != nil {
+		return nil, err
+	}
+	var valBytes []byte
+	if flag == 1 {
+		vl, err := c.uvarint()
+		if err != nil {
+			return nil, err
+		}
+		if valBytes, err = c.bytes(int(vl)); err != nil {
+			return nil, err
+		}
+	}
+
+	if s == len(key) {
+		if flag == 1 {
+			return decodeValue(valBytes)
+		}
+		return nil, nil
+	}
+
+	n, err := c.uvarint()
+	if err != nil {
+		return nil, err
+	}
+	cb, rest := key[s], key[s+1:]
+	for i := uint64(0); i < n; i++ {
+		b, err := c.byte1()
+		if err != nil {
+			return nil, err
+		}
+		sublen, err := c.uvarint()
+		if err != nil {
+			return nil, err
+		}
+		sub, err := c.bytes(int(sublen))
+		if err != nil {
+			return nil, err
+		}
+		if b == cb {
+			return getFrozen(sub, rest, decodeValue)
+		}
+		if b > cb {
+			break
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,178 @@
+// Copyright 2012 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package trie
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+)
+
+func init() {
+	gob.Register("")
+}
+
+func stringCodec() (func(interface{}) ([]byte, error), func([]byte) (interface{}, error)) {
+	enc := func(v interface{}) ([]byte, error) { return []byte(v.(string)), nil }
+	dec := func(b []byte) (interface{}, error) { return string(b), nil }
+	return enc, dec
+}
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	want, keys := mkSortedTC()
+	enc, dec := stringCodec()
+
+	var buf bytes.Buffer
+	if _, err := want.Encode(&buf, enc); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	var got Trie
+	if _, err := got.Decode(&buf, dec); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+
+	if got.Len() != want.Len() {
+		t.Fatalf("Len() = %d, want %d", got.Len(), want.Len())
+	}
+	for _, k := range keys {
+		if got.Get(k) != want.Get(k) {
+			t.Errorf("Get(%q) = %v, want %v", k, got.Get(k), want.Get(k))
+		}
+	}
+}
+
+func TestMarshalUnmarshalBinary(t *testing.T) {
+	var want Trie
+	for _, s := range tcAA {
+		want.Put(s, s)
+	}
+
+	b, err := want.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+
+	var got Trie
+	if err := got.UnmarshalBinary(b); err != nil {
+		t.Fatalf("UnmarshalBinary: %v", err)
+	}
+
+	if got.Len() != want.Len() {
+		t.Fatalf("Len() = %d, want %d", got.Len(), want.Len())
+	}
+	for _, s := range tcAA {
+		if got.Get(s) != s {
+			t.Errorf("Get(%q) = %v, want %q", s, got.Get(s), s)
+		}
+	}
+}
+
+func TestWriteToReadFrom(t *testing.T) {
+	var want Trie
+	want.Put("alpha", "alpha")
+	want.Put("beta", "beta")
+	want.Put("gamma", "gamma")
+
+	var buf bytes.Buffer
+	n, err := want.WriteTo(&buf)
+	if err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("WriteTo returned n = %d, want %d", n, buf.Len())
+	}
+
+	var got Trie
+	if _, err := got.ReadFrom(&buf); err != nil {
+		t.Fatalf("ReadFrom: %v", err)
+	}
+	for _, k := range []string{"alpha", "beta", "gamma"} {
+		if got.Get(k) != k {
+			t.Errorf("Get(%q) = %v, want %q", k, got.Get(k), k)
+		}
+	}
+}
+
+func TestFrozenGet(t *testing.T) {
+	tr, keys := mkSortedTC()
+	enc, dec := stringCodec()
+
+	var buf bytes.Buffer
+	if _, err := tr.Encode(&buf, enc); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	f := NewFrozen(buf.Bytes(), dec)
+	for _, k := range keys {
+		v, err := f.Get(k)
+		if err != nil {
+			t.Fatalf("Get(%q): %v", k, err)
+		}
+		if v != k {
+			t.Errorf("Get(%q) = %v, want %q", k, v, k)
+		}
+	}
+
+	for _, missing := range []string{"", "nonexistent-key-xyz", keys[0] + "tail"} {
+		v, err := f.Get(missing)
+		if err != nil {
+			t.Fatalf("Get(%q): %v", missing, err)
+		}
+		if v != nil {
+			t.Errorf("Get(%q) = %v, want nil", missing, v)
+		}
+	}
+}
+
+func TestEncodeOmitsDensePadding(t *testing.T) {
+	var tr Trie
+	const n = 64
+	// even bytes 0..126 only, so the root's dense slab spans 128 slots
+	// of which half are padding.
+	for i := 0; i < n; i++ {
+		tr.Put(string([]byte{byte(2 * i)})+"tail", i)
+	}
+	if len(tr.children) <= n {
+		t.Fatalf("root dense slab has %d slots, want padding beyond %d", len(tr.children), n)
+	}
+
+	enc := func(v interface{}) ([]byte, error) { return []byte{byte(v.(int))}, nil }
+	dec := func(b []byte) (interface{}, error) { return int(b[0]), nil }
+
+	var buf bytes.Buffer
+	if _, err := tr.Encode(&buf, enc); err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	// root: uvarint(0) suffix length, flag 0, then uvarint(childCount).
+	if got := buf.Bytes()[2]; got != n {
+		t.Errorf("encoded root child count = %d, want %d", got, n)
+	}
+
+	var got Trie
+	if _, err := got.Decode(&buf, dec); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if got.Len() != n {
+		t.Fatalf("Len() = %d, want %d", got.Len(), n)
+	}
+	for i := 0; i < n; i++ {
+		k := string([]byte{byte(2 * i)}) + "tail"
+		if got.Get(k) != i {
+			t.Errorf("Get(%q) = %v, want %d", k, got.Get(k), i)
+		}
+	}
+}
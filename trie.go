@@ -20,15 +20,128 @@ import (
 	"fmt"
 )
 
+// sparseMax is the largest number of distinct children a node keeps in
+// its sparse representation before it is promoted to the dense,
+// base+slice representation.
+const sparseMax = 8
+
+// A childEntry is one child of a node in sparse mode, kept sorted by c.
+type childEntry struct {
+	c     byte
+	child *Trie
+}
+
 // A Trie maintains a sorted collection of values keyed on a string.
 // Insertion is O(len(key)). Unlike Go's built-in map there is no
 // distinction between a nil and a non-existent value.
 // The zero value for Trie is an empty trie ready to use.
+//
+// A node's children are held in one of two representations: up to
+// sparseMax of them as a linearly scanned, sorted childEntry list
+// (sparse), or, once more than sparseMax distinct children exist, as a
+// dense base+slice array indexed by key[s]-base (dense). Only one of
+// sparse or children is ever non-nil for a given node. The mode is an
+// implementation detail and does not affect any method's behaviour.
 type Trie struct {
 	suffix   string
 	value    interface{}
+	sparse   []childEntry
 	children []Trie
 	base     byte
+	count    int // number of non-nil values at or under this node; only meaningful on the root
+}
+
+// empty reports whether t holds neither a value nor any children.
+func (t *Trie) empty() bool {
+	return t.value == nil && t.sparse == nil && t.children == nil
+}
+
+// child returns the child reached by byte c, or nil if there is none.
+func (t *Trie) child(c byte) *Trie {
+	if t.sparse != nil {
+		for _, e := range t.sparse {
+			if e.c == c {
+				return e.child
+			}
+			if e.c > c {
+				break
+			}
+		}
+		return nil
+	}
+	if c < t.base || int(c) >= int(t.base)+len(t.children) {
+		return nil
+	}
+	return &t.children[c-t.base]
+}
+
+// nextPow2Range grows the power-of-two range [base, base+ln) by doubling
+// until it also covers c, the way Put has always sized the dense slab.
+func nextPow2Range(base byte, ln int, c byte) (byte, int) {
+	for c < base || int(c) >= int(base)+ln {
+		ln *= 2
+		base &= ^byte(ln - 1)
+	}
+	return base, ln
+}
+
+// growDense resizes t.children, which must already be in dense mode, so
+// that byte c falls within range.
+func (t *Trie) growDense(c byte) {
+	newbase, newlen := nextPow2Range(t.base, len(t.children), c)
+	if newlen != len(t.children) {
+		newch := make([]Trie, newlen)
+		copy(newch[t.base-newbase:], t.children)
+		t.children = newch
+		t.base = newbase
+	}
+}
+
+// promote converts t from sparse to dense mode, growing the new slab to
+// also fit byte c.
+func (t *Trie) promote(c byte) {
+	base, ln := t.sparse[0].c, 1
+	for _, e := range t.sparse[1:] {
+		base, ln = nextPow2Range(base, ln, e.c)
+	}
+	base, ln = nextPow2Range(base, ln, c)
+
+	newch := make([]Trie, ln)
+	for _, e := range t.sparse {
+		newch[e.c-base] = *e.child
+	}
+	t.children = newch
+	t.base = base
+	t.sparse = nil
+}
+
+// putChild returns the child reached by byte c, creating it (and
+// growing or promoting the storage mode as needed) if it doesn't exist.
+func (t *Trie) putChild(c byte) *Trie {
+	if t.children != nil {
+		t.growDense(c)
+		return &t.children[c-t.base]
+	}
+
+	for i := range t.sparse {
+		if t.sparse[i].c == c {
+			return t.sparse[i].child
+		}
+	}
+
+	if len(t.sparse) < sparseMax {
+		t.sparse = append(t.sparse, childEntry{})
+		i := len(t.sparse) - 1
+		for i > 0 && t.sparse[i-1].c > c {
+			t.sparse[i] = t.sparse[i-1]
+			i--
+		}
+		t.sparse[i] = childEntry{c: c, child: new(Trie)}
+		return t.sparse[i].child
+	}
+
+	t.promote(c)
+	return t.putChild(c)
 }
 
 // Find the largest i such that a[:i] == b[:i]
@@ -48,67 +161,60 @@ func commonPrefix(a, b string) int {
 // Put inserts or replaces a value in the trie.  To remove a value
 // insert nil.
 func (t *Trie) Put(key string, value interface{}) {
-	if t.children == nil && t.value == nil { // empty node
+	t.count += t.put(key, value)
+}
+
+// put is the recursive implementation of Put. It returns +1 if this
+// call gave a key a non-nil value it didn't have before, -1 if it took
+// a non-nil value away, and 0 otherwise, so Put can maintain count on
+// the root with a single addition.
+func (t *Trie) put(key string, value interface{}) int {
+	if t.empty() { // empty node
 		t.suffix = key
 		t.value = value
-		return
+		if value != nil {
+			return 1
+		}
+		return 0
 	}
 
 	s := commonPrefix(t.suffix, key)
 
 	if s < len(t.suffix) {
 		// split on s: turn t into a node with suffix[:s]
-		// and move the contents to child[suffix[s]-t.base] with suffix[s+1:]
-		// we save the extra alloc on the common case that we'd insert a subtrie
-		// on key[s] immediately below by making children large enough
-		newbase := t.suffix[s]
-		newlen := 1
-		if s < len(key) {
-			for key[s] < newbase || int(key[s]) >= int(newbase)+newlen {
-				newlen *= 2
-				newbase &= ^byte(newlen - 1)
-			}
-		}
-		newch := make([]Trie, newlen)
-		newch[t.suffix[s]-newbase] = Trie{
+		// and move the contents to child[suffix[s]] with suffix[s+1:]
+		old := Trie{
 			suffix:   t.suffix[s+1:],
 			value:    t.value,
+			sparse:   t.sparse,
 			children: t.children,
 			base:     t.base,
 		}
+		oldc := t.suffix[s]
 
 		t.suffix = t.suffix[:s]
 		t.value = nil
-		t.children = newch
-		t.base = newbase
+		t.sparse = nil
+		t.children = nil
+		t.base = 0
+
+		*t.putChild(oldc) = old
 	}
 
 	if s == len(key) {
+		had, has := t.value != nil, value != nil
 		t.value = value
-		return
-	}
-
-	if len(t.children) == 0 {
-		t.children = make([]Trie, 1)
-		t.base = key[s]
-	} else {
-		newbase := t.base
-		newlen := len(t.children)
-		for key[s] < newbase || int(key[s]) >= int(newbase)+newlen {
-			newlen *= 2
-			newbase &= ^byte(newlen - 1)
-		}
-		if newlen != len(t.children) {
-			newch := make([]Trie, newlen)
-			copy(newch[t.base-newbase:], t.children)
-			t.children = newch
-			t.base = newbase
+		switch {
+		case has && !had:
+			return 1
+		case had && !has:
+			return -1
+		default:
+			return 0
 		}
 	}
 
-	t.children[key[s]-t.base].Put(key[s+1:], value)
-
-	return
+	return t.putChild(key[s]).put(key[s+1:], value)
 }
 
 // Get retrieves an element from the trie if it exists, or nil if it does not.
@@ -123,11 +229,12 @@ func (t *Trie) Get(key string) interface{} {
 		return t.value
 	}
 
-	if key[s] < t.base || int(key[s]) >= int(t.base)+len(t.children) {
+	c := t.child(key[s])
+	if c == nil {
 		return nil
 	}
 
-	return t.children[key[s]-t.base].Get(key[s+1:])
+	return c.Get(key[s+1:])
 }
 
 // FindPfx finds the longest prefix of key in the trie that has a non-nil value.
@@ -146,14 +253,15 @@ func (t *Trie) FindPfx(key string) (pfx string, val interface{}) {
 	}
 	// there's a bit of key left over.  if it is out of range, we're the longest prefix
 
-	if key[s] < t.base || int(key[s]) >= int(t.base)+len(t.children) {
+	c := t.child(key[s])
+	if c == nil {
 		if t.value != nil {
 			return t.suffix, t.value
 		}
 		return "", nil
 	}
 
-	p, v := t.children[key[s]-t.base].FindPfx(key[s+1:])
+	p, v := c.FindPfx(key[s+1:])
 	if v != nil {
 		return key[:s+1] + p, v
 	}
@@ -189,14 +297,15 @@ func (t *Trie) findAllPfx(key string, ofs int) []KV {
 	}
 	// there's a bit of key left over.  if it is out of range, we're the longest prefix
 
-	if key[s] < t.base || int(key[s]) >= int(t.base)+len(t.children) {
+	c := t.child(key[ofs+s])
+	if c == nil {
 		if t.value != nil {
 			return []KV{{key[:ofs+s], t.value}}
 		}
 		return nil
 	}
 
-	kv := t.children[key[s]-t.base].findAllPfx(key, ofs+s+1)
+	kv := c.findAllPfx(key, ofs+s+1)
 	if t.value != nil {
 		kv = append(kv, KV{key[:ofs+s], t.value})
 	}
@@ -218,15 +327,16 @@ func (t *Trie) subtrie(key string) (*Trie, int) {
 
 	// s == len(suffix) but s < len(key): there's a bit of key left over
 
-	if key[s] < t.base || int(key[s]) >= int(t.base)+len(t.children) {
+	c := t.child(key[s])
+	if c == nil {
 		return nil, 0
 	}
 
-	return t.children[key[s]-t.base].subtrie(key[s+1:])
+	return c.subtrie(key[s+1:])
 }
 
 func (t *Trie) forEach(f func([]byte, interface{}) bool, buf *bytes.Buffer) bool {
-	if t.value == nil && t.children == nil {
+	if t.empty() {
 		return true
 	}
 
@@ -237,7 +347,16 @@ func (t *Trie) forEach(f func([]byte, interface{}) bool, buf *bytes.Buffer) bool
 		return false
 	}
 
-	if t.children != nil {
+	if t.sparse != nil {
+		l := buf.Len()
+		buf.WriteByte(0)
+		for _, e := range t.sparse {
+			buf.Bytes()[l] = e.c
+			if !e.child.forEach(f, buf) {
+				return false
+			}
+		}
+	} else if t.children != nil {
 		l := buf.Len()
 		buf.WriteByte(t.base)
 		for _, v := range t.children {
@@ -307,20 +426,30 @@ func (t *Trie) dump(level int) {
 		level = len(spaces)
 	}
 	fmt.Printf("%s: %v\n", t.suffix, t.value)
-	if t.children != nil {
-		fmt.Printf("%s<%d>\n", spaces[:4*level], len(t.children))
-	}
-	c := t.base
-	for _, ch := range t.children {
-		if ch.value != nil || ch.children != nil {
-			if c >= 32 && c < 128 {
-				fmt.Printf("%s['%c']", spaces[:4*level], c)
+	if t.sparse != nil {
+		fmt.Printf("%s<sparse:%d>\n", spaces[:4*level], len(t.sparse))
+		for _, e := range t.sparse {
+			if e.c >= 32 && e.c < 128 {
+				fmt.Printf("%s['%c']", spaces[:4*level], e.c)
 			} else {
-				fmt.Printf("%s[%d]", spaces[:4*level], c)
+				fmt.Printf("%s[%d]", spaces[:4*level], e.c)
+			}
+			e.child.dump(level + 1)
+		}
+	} else if t.children != nil {
+		fmt.Printf("%s<%d>\n", spaces[:4*level], len(t.children))
+		c := t.base
+		for _, ch := range t.children {
+			if ch.value != nil || ch.sparse != nil || ch.children != nil {
+				if c >= 32 && c < 128 {
+					fmt.Printf("%s['%c']", spaces[:4*level], c)
+				} else {
+					fmt.Printf("%s[%d]", spaces[:4*level], c)
+				}
+				ch.dump(level + 1)
 			}
-			ch.dump(level + 1)
+			c++
 		}
-		c++
 	}
 }
 
@@ -329,6 +458,11 @@ func (t *Trie) shape() (ln, sz int) {
 		ln++
 	}
 	sz++
+	for _, e := range t.sparse {
+		l, s := e.child.shape()
+		ln += l
+		sz += s
+	}
 	for _, c := range t.children {
 		l, s := c.shape()
 		ln += l
@@ -397,6 +397,46 @@ func BenchmarkForEach100(b *testing.B)   { forEach(100, b) }
 func BenchmarkForEach1000(b *testing.B)  { forEach(1000, b) }
 func BenchmarkForEach10000(b *testing.B) { forEach(10000, b) }
 
+// benchmarkFanout builds a trie whose root has n distinct single-byte
+// children, to compare the sparse (n <= sparseMax) and dense (n >
+// sparseMax) child storage modes on the same shape of workload.
+func benchmarkFanout(n int, b *testing.B) {
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = string([]byte{byte(i)}) + "-common-suffix"
+	}
+
+	b.Run("Put", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			var tr Trie
+			for _, k := range keys {
+				tr.Put(k, k)
+			}
+		}
+	})
+
+	b.Run("Get", func(b *testing.B) {
+		var tr Trie
+		for _, k := range keys {
+			tr.Put(k, k)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			for _, k := range keys {
+				tr.Get(k)
+			}
+		}
+	})
+}
+
+// BenchmarkFanoutCompact8 stays within the sparse representation.
+func BenchmarkFanoutCompact8(b *testing.B) { benchmarkFanout(8, b) }
+
+// BenchmarkFanoutWide64 and BenchmarkFanoutWide250 force promotion to
+// the dense, base+slice representation.
+func BenchmarkFanoutWide64(b *testing.B)  { benchmarkFanout(64, b) }
+func BenchmarkFanoutWide250(b *testing.B) { benchmarkFanout(250, b) }
+
 func byteEqual(a, b []byte) bool {
 	if len(a) != len(b) {
 		return false